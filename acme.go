@@ -0,0 +1,257 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	IsACME     = flag.Bool("acme", false, "obtain a certificate from an ACME v2 directory")
+	AcmeDir    = flag.String("acme-dir", acme.LetsEncryptURL, "ACME directory `URL`")
+	Email      = flag.String("email", "", "contact e-mail used to register the ACME account")
+	Challenge  = flag.String("challenge", "http-01", "ACME challenge type: http-01, dns-01 or tls-alpn-01")
+	AccountKey = flag.String("account-key", "",
+		"path to the ACME account key (default Dir.Key/acme-account.key); created on first use if missing")
+
+	IsRenew   = flag.Bool("renew", false, "reissue certificates in Dir.Cert whose NotAfter is near")
+	RenewDays = flag.Int("renew-days", 30, "reissue with `-renew` certificates expiring within this many days")
+)
+
+// acmeAccountKeyFile returns the path of the ACME account key, honoring
+// -account-key when given.
+func acmeAccountKeyFile() string {
+	if *AccountKey != "" {
+		return *AccountKey
+	}
+	return filepath.Join(Dir.Key, "acme-account.key")
+}
+
+// loadOrCreateAccountKey loads the ACME account key, creating one the first
+// time it is needed.
+func loadOrCreateAccountKey() *ecdsa.PrivateKey {
+	file := acmeAccountKeyFile()
+
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		writeKey(file, key)
+		return key
+	}
+
+	key, ok := readKey(file).(*ecdsa.PrivateKey)
+	if !ok {
+		log.Fatalf("%q: account key must be ECDSA", file)
+	}
+	return key
+}
+
+// AcmeIssue obtains a certificate for the domains in -host from the ACME
+// directory at -acme-dir, writing it to File.Cert and its key to File.Key.
+func AcmeIssue() {
+	if *Host == "" {
+		log.Fatal("Missing required `-host` with the domains to certify")
+	}
+	domains := strings.Split(*Host, ",")
+
+	client := &acme.Client{Key: loadOrCreateAccountKey(), DirectoryURL: *AcmeDir}
+	ctx := context.Background()
+
+	if _, err := client.Register(ctx, &acme.Account{Contact: []string{"mailto:" + *Email}}, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		log.Fatal(err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		if err = completeChallenge(ctx, client, authz); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hosts := ParseHosts(domains)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: hosts.DNSNames,
+	}, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeKey(File.Key, key)
+	writeCertChain(File.Cert, der)
+
+	fmt.Printf("* Certificate for %s issued in %q\n", strings.Join(domains, ", "), File.Cert)
+}
+
+// completeChallenge fulfils one of authz's challenges, the one matching
+// -challenge, and waits for the CA to validate it.
+func completeChallenge(ctx context.Context, client *acme.Client, authz *acme.Authorization) error {
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == *Challenge {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("CA did not offer the %q challenge for %s", *Challenge, authz.Identifier.Value)
+	}
+
+	switch *Challenge {
+	case "http-01":
+		path := client.HTTP01ChallengePath(chal.Token)
+		body, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return err
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) })
+		srv := &http.Server{Addr: ":80", Handler: mux}
+		go srv.ListenAndServe()
+		defer srv.Close()
+
+	case "tls-alpn-01":
+		cert, err := client.TLSALPN01ChallengeCert(chal.Token, authz.Identifier.Value)
+		if err != nil {
+			return err
+		}
+
+		ln, err := tls.Listen("tcp", ":443", &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"acme-tls/1"},
+		})
+		if err != nil {
+			return err
+		}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					if err := conn.(*tls.Conn).Handshake(); err != nil {
+						log.Printf("tls-alpn-01: handshake with %s: %v", conn.RemoteAddr(), err)
+					}
+				}()
+			}
+		}()
+		defer ln.Close()
+
+	case "dns-01":
+		record, err := client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("* Create a TXT record for _acme-challenge.%s with value %q, then press Enter\n",
+			authz.Identifier.Value, record)
+		bufio.NewReader(os.Stdin).ReadString('\n')
+
+	default:
+		return fmt.Errorf("unknown challenge type: %q", *Challenge)
+	}
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	_, err := client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// writeCertChain PEM-encodes every DER certificate in chain, leaf first, and
+// writes them to filename.
+func writeCertChain(filename string, chain [][]byte) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, der := range chain {
+		if err = pem.Encode(file, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// Renew reissues through ACME every certificate in Dir.Cert that has DNS
+// names and whose NotAfter is within -renew-days.
+func Renew() {
+	match, err := filepath.Glob(filepath.Join(Dir.Cert, "*"+EXT_CERT))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	threshold := time.Now().AddDate(0, 0, *RenewDays)
+
+	for _, certFile := range match {
+		cert, err := x509.ParseCertificate(readBlock(certFile).Bytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if cert.NotAfter.After(threshold) {
+			continue
+		}
+		if len(cert.DNSNames) == 0 {
+			continue // Not an ACME-issued certificate we can reissue unattended.
+		}
+
+		name := strings.TrimSuffix(filepath.Base(certFile), EXT_CERT)
+		*Host = strings.Join(cert.DNSNames, ",")
+		File.Cert = certFile
+		File.Key = filepath.Join(Dir.Key, name+EXT_KEY)
+
+		fmt.Printf("* Renewing %q (expires %s)\n", name, cert.NotAfter.Format(time.RFC1123))
+		AcmeIssue()
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxSerialNumber is the upper bound used to generate random certificate
+// serial numbers (20 octets, as recommended by the CA/Browser Forum).
+var maxSerialNumber = new(big.Int).Lsh(big.NewInt(1), 159)
+
+// newSerialNumber returns a random positive serial number for a certificate.
+func newSerialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, maxSerialNumber)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
+
+// writeKey PEM-encodes key, whichever algorithm it was generated with, and
+// writes it to filename with restrictive permissions.
+func writeKey(filename string, key crypto.Signer) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	if err = pem.Encode(file, block); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readKey reads and parses the private key in filename, whichever algorithm
+// it was generated with.
+func readKey(filename string) crypto.Signer {
+	key, err := x509.ParsePKCS8PrivateKey(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		log.Fatalf("key in %q has no usable signer", filename)
+	}
+	return signer
+}
+
+// writeCert PEM-encodes the DER certificate der and writes it to filename.
+func writeCert(filename string, der []byte) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	if err = pem.Encode(file, block); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeRequest PEM-encodes the DER certificate request der and writes it to
+// filename.
+func writeRequest(filename string, der []byte) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	block := &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}
+	if err = pem.Encode(file, block); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// caAlgoFile is where the key algorithm (and, for ECDSA, curve) the CA was
+// built with gets recorded, so that requests created afterwards default to
+// a matching one.
+func caAlgoFile() string { return filepath.Join(Dir.Root, "ca.algo") }
+
+// writeCAAlgo records the key algorithm currently selected through -key-algo
+// (and -ecdsa-curve) as the one the CA was built with.
+func writeCAAlgo() {
+	algo := string(KeyAlgo)
+	if KeyAlgo == KeyAlgoECDSA {
+		algo += " " + string(ECDSACurve)
+	}
+	if err := ioutil.WriteFile(caAlgoFile(), []byte(algo+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadCAAlgo sets KeyAlgo and ECDSACurve from the CA's recorded algorithm,
+// unless one was already chosen through -key-algo or a profile's key_algo.
+func loadCAAlgo() {
+	if keyAlgoSet {
+		return
+	}
+
+	data, err := ioutil.ReadFile(caAlgoFile())
+	if err != nil {
+		return // No CA built yet: keep the default.
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return
+	}
+	KeyAlgo = keyAlgoT(fields[0])
+	if len(fields) > 1 {
+		ECDSACurve = ecdsaCurveT(fields[1])
+	}
+}
+
+// readBlock reads filename and decodes its first PEM block.
+func readBlock(filename string) *pem.Block {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		log.Fatalf("no PEM data found in %q", filename)
+	}
+	return block
+}
+
+// loadCA parses the CA's certificate and loads its private key, whether it
+// is kept in a file or in a PKCS#11 token.
+func loadCA() (*x509.Certificate, crypto.Signer) {
+	certFile := filepath.Join(Dir.Cert, _NAME_CA+EXT_CERT)
+
+	cert, err := x509.ParseCertificate(readBlock(certFile).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if loadCABackend() {
+		return cert, hsmSigner()
+	}
+	return cert, readKey(filepath.Join(Dir.Key, _NAME_CA+EXT_KEY))
+}
+
+// subjectName returns the common name to use for a certificate or request
+// built for File.Request / File.Cert, derived from its base filename.
+func subjectName(filename, ext string) string {
+	return strings.TrimSuffix(filepath.Base(filename), ext)
+}
+
+// NewRequest creates a new private key and the certificate request for it,
+// writing both to File.Key and File.Request.
+func NewRequest() {
+	loadCAAlgo()
+
+	key, err := GenerateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: subjectName(File.Request, EXT_REQUEST)},
+	}
+	if *Host != "" {
+		hosts := ParseHosts(strings.Split(*Host, ","))
+		template.DNSNames = hosts.DNSNames
+		template.IPAddresses = hosts.IPAddresses
+		template.EmailAddresses = hosts.EmailAddresses
+		template.URIs = hosts.URIs
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeKey(File.Key, key)
+	writeRequest(File.Request, der)
+
+	fmt.Printf("* Certificate request created in %q\n", File.Request)
+}
+
+// SignReq signs the certificate request in File.Request with the CA's key,
+// writing the resulting certificate to File.Cert.
+func SignReq() {
+	csr, err := x509.ParseCertificateRequest(readBlock(File.Request).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = csr.CheckSignature(); err != nil {
+		log.Fatal(err)
+	}
+
+	caCert, caKey := loadCA()
+
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               csr.Subject,
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		EmailAddresses:        csr.EmailAddresses,
+		URIs:                  csr.URIs,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(*Years, 0, 0),
+		KeyUsage:              keyUsageFor(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment),
+		ExtKeyUsage:           extKeyUsageFor([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}),
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeCert(File.Cert, der)
+	appendIndex(template)
+
+	fmt.Printf("* Certificate signed in %q\n", File.Cert)
+}
+
+// BuildCA creates the private key and self-signed certificate for the
+// certification authority, writing them to File.Key and File.Cert — or, with
+// -hsm, generating the key on a PKCS#11 token instead.
+func BuildCA() {
+	var key crypto.Signer
+	var err error
+	if *UseHSM {
+		key, err = hsmGenerateKey()
+	} else {
+		key, err = GenerateKey()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: _NAME_CA},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(*Years, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	applyCAConstraints(template)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !*UseHSM {
+		writeKey(File.Key, key)
+	}
+	writeCert(File.Cert, der)
+	writeCAAlgo()
+	writeCABackend()
+
+	fmt.Printf("* Certification authority created in %q\n", File.Cert)
+}
@@ -10,11 +10,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"go/build"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
@@ -25,13 +23,9 @@ import (
 )
 
 const (
-	// Where the configuration template is installed through "go get".
-	_DIR_CONFIG = "github.com/kless/easycert/data"
-
 	_DIR_ROOT = ".cert" // Directory to store the certificates.
 	_NAME_CA  = "ca"    // Name for files related to the CA.
 
-	_FILE_CONFIG    = "openssl.cfg"
 	_FILE_SERVER_GO = "z-cert_srv.go"
 	_FILE_CLIENT_GO = "z-cert_cl.go"
 )
@@ -57,18 +51,11 @@ type DirPath struct {
 	Cert  string // Where the server certificates are placed.
 	Key   string // Where the private keys are placed.
 	Revok string // Where the certificate revokation list is placed.
-
-	// Where OpenSSL puts the created certificates in PEM (unencrypted) format
-	// and in the form 'cert_serial_number.pem' (e.g. '07.pem')
-	NewCert string
 }
 
 // FilePath represents the files structure.
 type FilePath struct {
-	Cmd    string // OpenSSL' path
-	Config string // OpenSSL configuration file.
-	Index  string // Serves as a database for OpenSSL.
-	Serial string // Contains the next certificate’s serial number.
+	Index string // Serves as a database of issued certificates.
 
 	Cert    string // Certificate.
 	Key     string // Private key.
@@ -85,11 +72,6 @@ func init() {
 	log.SetFlags(0)
 	log.SetPrefix("FAIL! ")
 
-	cmdPath, err := exec.LookPath("openssl")
-	if err != nil {
-		log.Fatal("OpenSSL is not installed")
-	}
-
 	user, err := user.Current()
 	if err != nil {
 		log.Fatal(err)
@@ -98,18 +80,14 @@ func init() {
 	root := filepath.Join(user.HomeDir, _DIR_ROOT)
 
 	Dir = &DirPath{
-		Root:    root,
-		Cert:    filepath.Join(root, "certs"),
-		NewCert: filepath.Join(root, "newcerts"),
-		Key:     filepath.Join(root, "private"),
-		Revok:   filepath.Join(root, "crl"),
+		Root:  root,
+		Cert:  filepath.Join(root, "certs"),
+		Key:   filepath.Join(root, "private"),
+		Revok: filepath.Join(root, "crl"),
 	}
 
 	File = &FilePath{
-		Cmd:    cmdPath,
-		Config: filepath.Join(Dir.Root, _FILE_CONFIG),
-		Index:  filepath.Join(Dir.Root, "index.txt"),
-		Serial: filepath.Join(Dir.Root, "serial"),
+		Index: filepath.Join(Dir.Root, "index.txt"),
 	}
 }
 
@@ -153,7 +131,8 @@ var (
 
 	IsRequest = flag.Bool("req", false, "create a certificate request")
 	IsSignReq = flag.Bool("sign", false, "sign a certificate request")
-	Host      = flag.String("host", "", "comma-separated hostnames and IPs to generate a certificate for")
+	Host      = flag.String("host", "",
+		"comma-separated hostnames, IPs, emails or URIs to generate a certificate for")
 
 	IsGoLang   = flag.Bool("lang-go", false, "generate files in Go language to handle some certificate")
 	CACert     = flag.String("ca-cert", _NAME_CA, "name or file of CA's certificate")
@@ -173,6 +152,11 @@ var (
 
 	IsCertList = flag.Bool("lc", false, "list the certificates built")
 	IsReqList  = flag.Bool("lr", false, "list the request certificates built")
+
+	RevokeName = flag.String("revoke", "", "name of the certificate to revoke")
+	IsGenCRL   = flag.Bool("gen-crl", false, "generate a CRL with the certificates revoked")
+	OCSPAddr   = flag.String("ocsp-serve", "", "address (`:PORT`) where to serve OCSP requests")
+	IsVerify   = flag.Bool("verify", false, "verify a certificate against the CRL and, if present, OCSP")
 )
 
 func init() {
@@ -186,10 +170,12 @@ NOTE: FILENAME is the path of a certificate file, while NAME is the name
 of a file to look for in the certificates directory.
 
 * Directory:
-  -new [-ca -rsa-size -years]
+  -new [-ca -key-algo -rsa-size -ecdsa-curve -years]
+       [-hsm -pkcs11-module -pkcs11-token -pkcs11-label]
 
 * Certificate requests:
-  -req [-rsa-size -years] [-sign] [-host] NAME
+  -req [-key-algo -rsa-size -ecdsa-curve -years] [-sign] [-host] NAME
+  -req [-config -profile] NAME
   -sign NAME
 
 * Create files for some language:
@@ -206,6 +192,20 @@ of a file to look for in the certificates directory.
 * List:
   -lc -lr
 
+* Revocation:
+  -revoke NAME
+  -gen-crl
+  -ocsp-serve :PORT
+  -verify [-cert] NAME|FILENAME
+
+* ACME (Let's Encrypt):
+  -acme -host [-acme-dir -email -challenge -account-key] NAME
+  -renew [-renew-days]
+
+* PKCS#12 bundles:
+  -p12 NAME
+  -import-p12 FILE
+
 The flags are:
 `)
 
@@ -222,6 +222,8 @@ func main() {
 		os.Exit(2)
 	}
 
+	ApplyProfile()
+
 	isExit := false
 
 	if len(flag.Args()) == 0 {
@@ -272,7 +274,15 @@ func main() {
 
 	case *IsNew:
 
+	case *RevokeName != "", *IsGenCRL, *OCSPAddr != "", *IsRenew, *P12Name != "", *ImportP12 != "":
+		// These flags carry their own argument, if any (-revoke NAME,
+		// -ocsp-serve :PORT, -p12 NAME, -import-p12 FILE) or take none
+		// (-gen-crl, -renew); none of them consume a positional NAME.
+
 	default:
+		if len(flag.Args()) == 0 {
+			log.Fatal("Missing NAME or FILENAME argument")
+		}
 		filename = flag.Args()[0]
 
 		if filename[0] != '.' && filename[0] != os.PathSeparator {
@@ -284,6 +294,44 @@ func main() {
 		}
 	}
 
+	if *IsACME {
+		name := flag.Args()[0]
+		File.Cert = filepath.Join(Dir.Cert, name+EXT_CERT)
+		File.Key = filepath.Join(Dir.Key, name+EXT_KEY)
+		AcmeIssue()
+		os.Exit(0)
+	}
+	if *IsRenew {
+		Renew()
+		os.Exit(0)
+	}
+
+	if *RevokeName != "" {
+		Revoke(*RevokeName)
+		os.Exit(0)
+	}
+	if *IsGenCRL {
+		GenCRL()
+		os.Exit(0)
+	}
+	if *OCSPAddr != "" {
+		OCSPServe(*OCSPAddr)
+		os.Exit(0)
+	}
+	if *IsVerify {
+		Verify(filename)
+		os.Exit(0)
+	}
+
+	if *P12Name != "" {
+		ExportP12(*P12Name)
+		os.Exit(0)
+	}
+	if *ImportP12 != "" {
+		ImportP12File(*ImportP12)
+		os.Exit(0)
+	}
+
 	if *IsCheck {
 		if *IsCert {
 			CheckCert(filename)
@@ -370,7 +418,7 @@ func main() {
 func SetupDir() {
 	var err error
 
-	for _, v := range []string{Dir.Root, Dir.Cert, Dir.NewCert, Dir.Key, Dir.Revok} {
+	for _, v := range []string{Dir.Root, Dir.Cert, Dir.Key, Dir.Revok} {
 		if err = os.Mkdir(v, 0755); err != nil {
 			log.Fatal(err)
 		}
@@ -385,64 +433,6 @@ func SetupDir() {
 	}
 	file.Close()
 
-	file, err = os.Create(File.Serial)
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = file.Write([]byte{'0', '1', '\n'})
-	file.Close()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Configuration template
-
-	host, err := os.Hostname()
-	if err != nil {
-		log.Fatalf("Could not get hostname: %s\n\n"+
-			"You may want to fix your '/etc/hosts' and/or DNS setup",
-			err)
-	}
-
-	pkg, err := build.Import(_DIR_CONFIG, build.Default.GOPATH, build.FindOnly)
-	if err != nil {
-		log.Fatal("Data directory not found\n", err)
-	}
-
-	configTemplate := filepath.Join(pkg.Dir, _FILE_CONFIG+".tmpl")
-	if _, err = os.Stat(configTemplate); os.IsNotExist(err) {
-		log.Fatalf("Configuration template not found: %q", configTemplate)
-	}
-
-	tmpl, err := template.ParseFiles(configTemplate)
-	if err != nil {
-		log.Fatal("Parsing error in configuration: ", err)
-	}
-
-	tmpConfigFile, err := os.Create(File.Config)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	data := struct {
-		RootDir  string
-		HostName string
-		AltNames string
-	}{
-		Dir.Root,
-		host,
-		"IP.1 = 127.0.0.1",
-	}
-	err = tmpl.Execute(tmpConfigFile, data)
-	tmpConfigFile.Close()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err = os.Chmod(File.Config, 0600); err != nil {
-		log.Print(err)
-	}
-
 	fmt.Printf("* Directory structure created in %q\n", Dir.Root)
 }
 
@@ -461,11 +451,6 @@ func Cert2Lang() {
 		log.Fatal(err)
 	}
 
-	version, err := exec.Command(File.Cmd, "version").Output()
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	// Server
 
 	file, err := os.OpenFile(_FILE_SERVER_GO, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
@@ -486,7 +471,7 @@ func Cert2Lang() {
 	}{
 		runtime.GOOS,
 		runtime.GOARCH,
-		strings.TrimRight(string(version), "\n"),
+		runtime.Version(),
 		time.Now().Format(time.RFC822),
 		fmt.Sprint(strings.TrimRight(InfoEndDate(File.Cert), "\n")),
 		GoBlock(CACertBlock).String(),
@@ -0,0 +1,54 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// ParsedHosts groups the -host entries classified by kind, ready to be
+// assigned to the Subject Alternative Name fields of an x509.Certificate or
+// x509.CertificateRequest.
+type ParsedHosts struct {
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+}
+
+// ParseHosts classifies each entry of hosts as an IP address, an email
+// address, a URI or a DNS name. Wildcard hostnames (e.g. "*.example.com")
+// are kept as DNS names.
+func ParseHosts(hosts []string) *ParsedHosts {
+	parsed := new(ParsedHosts)
+
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			parsed.IPAddresses = append(parsed.IPAddresses, ip)
+			continue
+		}
+		if addr, err := mail.ParseAddress(host); err == nil {
+			parsed.EmailAddresses = append(parsed.EmailAddresses, addr.Address)
+			continue
+		}
+		if u, err := url.Parse(host); err == nil && u.Scheme != "" && u.Host != "" {
+			parsed.URIs = append(parsed.URIs, u)
+			continue
+		}
+		parsed.DNSNames = append(parsed.DNSNames, host)
+	}
+
+	return parsed
+}
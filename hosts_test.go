@@ -0,0 +1,50 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import "testing"
+
+func TestParseHosts(t *testing.T) {
+	got := ParseHosts([]string{
+		"example.com",
+		"*.example.com",
+		"192.168.1.1",
+		"::1",
+		"user@example.com",
+		"https://example.com/path",
+		" ",
+		"",
+	})
+
+	wantDNS := []string{"example.com", "*.example.com"}
+	if len(got.DNSNames) != len(wantDNS) {
+		t.Fatalf("DNSNames = %v, want %v", got.DNSNames, wantDNS)
+	}
+	for i, v := range wantDNS {
+		if got.DNSNames[i] != v {
+			t.Errorf("DNSNames[%d] = %q, want %q", i, got.DNSNames[i], v)
+		}
+	}
+
+	if len(got.IPAddresses) != 2 {
+		t.Fatalf("IPAddresses = %v, want 2 entries", got.IPAddresses)
+	}
+	if got.IPAddresses[0].String() != "192.168.1.1" {
+		t.Errorf("IPAddresses[0] = %v, want 192.168.1.1", got.IPAddresses[0])
+	}
+	if got.IPAddresses[1].String() != "::1" {
+		t.Errorf("IPAddresses[1] = %v, want ::1", got.IPAddresses[1])
+	}
+
+	if len(got.EmailAddresses) != 1 || got.EmailAddresses[0] != "user@example.com" {
+		t.Errorf("EmailAddresses = %v, want [user@example.com]", got.EmailAddresses)
+	}
+
+	if len(got.URIs) != 1 || got.URIs[0].String() != "https://example.com/path" {
+		t.Errorf("URIs = %v, want [https://example.com/path]", got.URIs)
+	}
+}
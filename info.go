@@ -0,0 +1,148 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckCert reports whether filename holds a well-formed certificate.
+func CheckCert(filename string) {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q: certificate OK (subject: %s)\n", filepath.Base(filename), cert.Subject)
+}
+
+// CheckKey reports whether filename holds a well-formed, consistent private
+// key, whichever algorithm it was generated with.
+func CheckKey(filename string) {
+	key, err := x509.ParsePKCS8PrivateKey(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		if err = rsaKey.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("%q: key OK\n", filepath.Base(filename))
+}
+
+// InfoCert returns human-readable information about the certificate in
+// filename.
+func InfoCert(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return fmt.Sprintf(
+		"Subject: %s\nIssuer: %s\nSerial number: %s\nNot before: %s\nNot after: %s\n",
+		cert.Subject, cert.Issuer, cert.SerialNumber,
+		cert.NotBefore.Format(time.RFC1123), cert.NotAfter.Format(time.RFC1123),
+	)
+}
+
+// InfoKey returns human-readable information about the private key in
+// filename.
+func InfoKey(filename string) string {
+	key, err := x509.ParsePKCS8PrivateKey(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return fmt.Sprintf("RSA private key, %d bits\n", k.N.BitLen())
+	case *ecdsa.PrivateKey:
+		return fmt.Sprintf("ECDSA private key, curve %s\n", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return "Ed25519 private key\n"
+	default:
+		return fmt.Sprintf("private key of type %T\n", k)
+	}
+}
+
+// InfoFull returns extensive information about the certificate in filename,
+// including its Subject Alternative Names.
+func InfoFull(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	info := InfoCert(filename)
+	if len(cert.DNSNames) != 0 {
+		info += fmt.Sprintf("DNS names: %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	if len(cert.IPAddresses) != 0 {
+		ips := make([]string, len(cert.IPAddresses))
+		for i, ip := range cert.IPAddresses {
+			ips[i] = ip.String()
+		}
+		info += fmt.Sprintf("IP addresses: %s\n", strings.Join(ips, ", "))
+	}
+	if len(cert.EmailAddresses) != 0 {
+		info += fmt.Sprintf("Email addresses: %s\n", strings.Join(cert.EmailAddresses, ", "))
+	}
+	if len(cert.URIs) != 0 {
+		uris := make([]string, len(cert.URIs))
+		for i, u := range cert.URIs {
+			uris[i] = u.String()
+		}
+		info += fmt.Sprintf("URIs: %s\n", strings.Join(uris, ", "))
+	}
+	return info
+}
+
+// InfoEndDate returns the date until the certificate in filename is valid.
+func InfoEndDate(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cert.NotAfter.Format(time.RFC1123) + "\n"
+}
+
+// HashInfo returns the SHA-1 fingerprint of the certificate in filename.
+func HashInfo(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sum := sha1.Sum(cert.Raw)
+	return fmt.Sprintf("%x\n", sum)
+}
+
+// InfoIssuer returns the issuer of the certificate in filename.
+func InfoIssuer(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cert.Issuer.String() + "\n"
+}
+
+// InfoName returns the subject of the certificate in filename.
+func InfoName(filename string) string {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cert.Subject.String() + "\n"
+}
@@ -0,0 +1,108 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"flag"
+	"fmt"
+)
+
+// keyAlgoT represents the public-key algorithm used to generate a new key.
+type keyAlgoT string
+
+// Key algorithms available through the -key-algo flag.
+const (
+	KeyAlgoRSA     keyAlgoT = "rsa"
+	KeyAlgoECDSA   keyAlgoT = "ecdsa"
+	KeyAlgoEd25519 keyAlgoT = "ed25519"
+)
+
+// keyAlgoSet reports whether the key algorithm was chosen through Set, by
+// -key-algo or by a profile's key_algo, as opposed to keeping its default.
+var keyAlgoSet bool
+
+func (a *keyAlgoT) Set(value string) error {
+	switch keyAlgoT(value) {
+	case KeyAlgoRSA, KeyAlgoECDSA, KeyAlgoEd25519:
+		*a = keyAlgoT(value)
+		keyAlgoSet = true
+		return nil
+	}
+	return fmt.Errorf("unknown key algorithm: %q", value)
+}
+
+func (a *keyAlgoT) String() string { return string(*a) }
+
+// ecdsaCurveT represents the elliptic curve used with -key-algo=ecdsa.
+type ecdsaCurveT string
+
+// Curves available through the -ecdsa-curve flag.
+const (
+	CurveP256 ecdsaCurveT = "P256"
+	CurveP384 ecdsaCurveT = "P384"
+	CurveP521 ecdsaCurveT = "P521"
+)
+
+func (c *ecdsaCurveT) Set(value string) error {
+	switch ecdsaCurveT(value) {
+	case CurveP256, CurveP384, CurveP521:
+		*c = ecdsaCurveT(value)
+		return nil
+	}
+	return fmt.Errorf("unknown ECDSA curve: %q", value)
+}
+
+func (c *ecdsaCurveT) String() string { return string(*c) }
+
+// curve returns the elliptic.Curve named by c.
+func (c ecdsaCurveT) curve() (elliptic.Curve, error) {
+	switch c {
+	case CurveP256:
+		return elliptic.P256(), nil
+	case CurveP384:
+		return elliptic.P384(), nil
+	case CurveP521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unknown ECDSA curve: %q", c)
+}
+
+var (
+	KeyAlgo    keyAlgoT    = KeyAlgoRSA
+	ECDSACurve ecdsaCurveT = CurveP256
+)
+
+func init() {
+	flag.Var(&KeyAlgo, "key-algo", "key algorithm to generate: rsa, ecdsa or ed25519")
+	flag.Var(&ECDSACurve, "ecdsa-curve", "elliptic curve to use with `-key-algo=ecdsa`: P256, P384 or P521")
+}
+
+// GenerateKey creates a new private key using the algorithm selected through
+// -key-algo, and -ecdsa-curve or -rsa-size where it applies.
+func GenerateKey() (crypto.Signer, error) {
+	switch KeyAlgo {
+	case KeyAlgoECDSA:
+		curve, err := ECDSACurve.curve()
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+
+	case KeyAlgoEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+
+	default:
+		return rsa.GenerateKey(rand.Reader, int(RSASize))
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"testing"
+)
+
+// TestGenerateKeyPerAlgorithm checks that -key-algo selects the matching
+// crypto.Signer concrete type; PKCS#12 export/import has to round-trip
+// whichever one the CA or a server certificate was issued with.
+func TestGenerateKeyPerAlgorithm(t *testing.T) {
+	origAlgo, origCurve, origSize := KeyAlgo, ECDSACurve, RSASize
+	defer func() { KeyAlgo, ECDSACurve, RSASize = origAlgo, origCurve, origSize }()
+
+	tests := []struct {
+		algo keyAlgoT
+		want func(interface{}) bool
+	}{
+		{KeyAlgoRSA, func(k interface{}) bool { _, ok := k.(*rsa.PrivateKey); return ok }},
+		{KeyAlgoECDSA, func(k interface{}) bool { _, ok := k.(*ecdsa.PrivateKey); return ok }},
+		{KeyAlgoEd25519, func(k interface{}) bool { _, ok := k.(ed25519.PrivateKey); return ok }},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algo), func(t *testing.T) {
+			KeyAlgo = tt.algo
+			ECDSACurve = CurveP256
+			RSASize = 2048
+
+			key, err := GenerateKey()
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			if !tt.want(key) {
+				t.Errorf("GenerateKey() returned %T, want the %s key type", key, tt.algo)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import "fmt"
+
+// GoBlock represents the bytes of a PEM block to be embedded as a Go byte
+// slice literal.
+type GoBlock []byte
+
+// String returns b formatted as a Go byte slice literal.
+func (b GoBlock) String() string {
+	s := "[]byte{"
+	for i, c := range b {
+		if i%12 == 0 {
+			s += "\n\t"
+		}
+		s += fmt.Sprintf("0x%02x, ", c)
+	}
+	return s + "\n}"
+}
+
+// TMPL_SERVER_GO is the template used to generate the file with the server's
+// certificate, private key and the CA's certificate embedded.
+const TMPL_SERVER_GO = `// Generated by easycert on {{.Date}} using {{.System}}/{{.Arch}}, {{.Version}}.
+// The certificate is valid until {{.ValidUntil}}.
+
+package main
+
+// CACert is the certificate of the certification authority.
+var CACert = {{.CACert}}
+
+// Cert is the server's certificate.
+var Cert = {{.Cert}}
+
+// Key is the server's private key.
+var Key = {{.Key}}
+`
+
+// TMPL_CLIENT_GO is the template used to generate the file with the CA's
+// certificate needed by a client to trust the server above.
+const TMPL_CLIENT_GO = `// Generated by easycert on {{.Date}} using {{.System}}/{{.Arch}}, {{.Version}}.
+// The certificate is valid until {{.ValidUntil}}.
+
+package main
+
+// CACert is the certificate of the certification authority.
+var CACert = {{.CACert}}
+`
@@ -0,0 +1,128 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspStatus reports the ocsp.Good/ocsp.Revoked/ocsp.Unknown status of
+// serial, and since when it was revoked, by looking it up in File.Index.
+func ocspStatus(serial *big.Int) (status int, revokedAt time.Time) {
+	for _, r := range readIndex() {
+		if r.Serial.Cmp(serial) != 0 {
+			continue
+		}
+		if r.Status == statusRevoked {
+			return ocsp.Revoked, r.RevokedAt
+		}
+		return ocsp.Good, time.Time{}
+	}
+	return ocsp.Unknown, time.Time{}
+}
+
+// OCSPServe starts an HTTP server at addr answering OCSP requests for
+// certificates issued by the CA.
+func OCSPServe(addr string) {
+	caCert, caKey := loadCA()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		reqBytes, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ocspReq, err := ocsp.ParseRequest(reqBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status, revokedAt := ocspStatus(ocspReq.SerialNumber)
+
+		respBytes, err := ocsp.CreateResponse(caCert, caCert, ocsp.Response{
+			Status:       status,
+			SerialNumber: ocspReq.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().AddDate(0, 0, 7),
+			RevokedAt:    revokedAt,
+		}, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(respBytes)
+	})
+
+	fmt.Printf("* OCSP responder listening on %q\n", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+// Verify validates the certificate in filename against the CA's CRL and,
+// when the certificate advertises an OCSP responder, against it too.
+func Verify(filename string) {
+	cert, err := x509.ParseCertificate(readBlock(filename).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if revoked, at := checkCRL(cert.SerialNumber); revoked {
+		log.Fatalf("%q: certificate revoked (CRL, %s)", filepath.Base(filename), at.Format(time.RFC1123))
+	}
+
+	if len(cert.OCSPServer) != 0 {
+		status, err := checkOCSP(cert)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if status == ocsp.Revoked {
+			log.Fatalf("%q: certificate revoked (OCSP)", filepath.Base(filename))
+		}
+	}
+
+	fmt.Printf("%q: certificate valid\n", filepath.Base(filename))
+}
+
+// checkOCSP queries cert's OCSP responder and returns its status.
+func checkOCSP(cert *x509.Certificate) (int, error) {
+	caCert, _ := loadCA()
+
+	reqBytes, err := ocsp.CreateRequest(cert, caCert, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.Post(cert.OCSPServer[0], "application/ocsp-request", bytes.NewReader(reqBytes))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	ocspResp, err := ocsp.ParseResponse(respBytes, caCert)
+	if err != nil {
+		return 0, err
+	}
+	return ocspResp.Status, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	P12Name   = flag.String("p12", "", "package NAME's certificate, key and CA chain as a PKCS#12 bundle")
+	ImportP12 = flag.String("import-p12", "", "split a PKCS#12 `FILE` into Dir.Cert/Dir.Key")
+)
+
+// ExportP12 packages the certificate named name, its key and the CA's
+// certificate into a password-protected PKCS#12 file.
+func ExportP12(name string) {
+	key := readKey(filepath.Join(Dir.Key, name+EXT_KEY))
+
+	cert, err := x509.ParseCertificate(readBlock(filepath.Join(Dir.Cert, name+EXT_CERT)).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	caCert, _ := loadCA()
+
+	der, err := pkcs12.Modern.Encode(key, cert, []*x509.Certificate{caCert}, readPassword("Password for the PKCS#12 bundle: "))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file := filepath.Join(Dir.Cert, name+".p12")
+	if err = ioutil.WriteFile(file, der, 0600); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("* PKCS#12 bundle created in %q\n", file)
+}
+
+// ImportP12File splits the PKCS#12 bundle in file back into Dir.Cert and
+// Dir.Key, naming the files after file's base name.
+func ImportP12File(file string) {
+	der, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(der, readPassword("Password for the PKCS#12 bundle: "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		log.Fatalf("%q: private key has no usable signer", file)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+	writeKey(filepath.Join(Dir.Key, name+EXT_KEY), signer)
+	writeCert(filepath.Join(Dir.Cert, name+EXT_CERT), cert.Raw)
+	for i, ca := range caCerts {
+		writeCert(filepath.Join(Dir.Cert, fmt.Sprintf("%s-ca%d%s", name, i, EXT_CERT)), ca.Raw)
+	}
+
+	fmt.Printf("* Imported %q as %q\n", file, name)
+}
+
+// readPassword prompts on stderr and reads a line from stdin without
+// echoing it to the terminal.
+func readPassword(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(password)
+}
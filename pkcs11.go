@@ -0,0 +1,132 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/eclipse-keypont/crypto11"
+)
+
+var (
+	UseHSM       = flag.Bool("hsm", false, "store the CA's private key in a PKCS#11 token instead of a file")
+	PKCS11Module = flag.String("pkcs11-module", defaultPKCS11Module(), "path to the PKCS#11 module")
+	PKCS11Token  = flag.String("pkcs11-token", "", "label of the PKCS#11 slot/token to use")
+	PKCS11Label  = flag.String("pkcs11-label", _NAME_CA, "label of the CA key object in the token")
+)
+
+// defaultPKCS11Module returns the usual location of the SoftHSM2 module for
+// the running OS.
+func defaultPKCS11Module() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/usr/local/lib/softhsm/libsofthsm2.so"
+	case "windows":
+		return `C:\SoftHSM2\lib\softhsm2.dll`
+	default:
+		return "/usr/lib/softhsm/libsofthsm2.so"
+	}
+}
+
+// hsmCtx caches the dialed PKCS#11 session for the lifetime of the process.
+var hsmCtx *crypto11.Context
+
+// hsmContext dials the module configured through -pkcs11-module and
+// -pkcs11-token.
+func hsmContext() *crypto11.Context {
+	if hsmCtx != nil {
+		return hsmCtx
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       *PKCS11Module,
+		TokenLabel: *PKCS11Token,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	hsmCtx = ctx
+	return hsmCtx
+}
+
+// hsmGenerateKey creates the CA's keypair, labelled -pkcs11-label, on the
+// PKCS#11 token selected by -pkcs11-token, returning a crypto.Signer that performs every subsequent
+// operation on the token itself.
+func hsmGenerateKey() (crypto.Signer, error) {
+	id := []byte(*PKCS11Label)
+
+	switch KeyAlgo {
+	case KeyAlgoECDSA:
+		curve, err := ECDSACurve.curve()
+		if err != nil {
+			return nil, err
+		}
+		return hsmContext().GenerateECDSAKeyPairWithLabel(id, id, curve)
+
+	case KeyAlgoEd25519:
+		return nil, fmt.Errorf("the PKCS#11 backend does not support ed25519 keys")
+
+	default:
+		return hsmContext().GenerateRSAKeyPairWithLabel(id, id, int(RSASize))
+	}
+}
+
+// hsmSigner looks up the CA's existing keypair on the PKCS#11 token.
+func hsmSigner() crypto.Signer {
+	id := []byte(*PKCS11Label)
+
+	signer, err := hsmContext().FindKeyPair(id, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if signer == nil {
+		log.Fatalf("key %q not found in the PKCS#11 token", *PKCS11Label)
+	}
+	return signer
+}
+
+// caBackendFile records whether the CA's key lives on disk or in a PKCS#11
+// token, so later commands know how to load it again without -hsm being
+// passed every time.
+func caBackendFile() string { return filepath.Join(Dir.Root, "ca.backend") }
+
+// writeCABackend persists the current -hsm configuration for the CA just
+// built.
+func writeCABackend() {
+	if !*UseHSM {
+		return
+	}
+
+	line := fmt.Sprintf("hsm\t%s\t%s\t%s\n", *PKCS11Module, *PKCS11Token, *PKCS11Label)
+	if err := ioutil.WriteFile(caBackendFile(), []byte(line), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadCABackend reports whether the CA's key is stored in a PKCS#11 token
+// and, if so, restores the flags needed to reach it.
+func loadCABackend() bool {
+	data, err := ioutil.ReadFile(caBackendFile())
+	if err != nil {
+		return false
+	}
+
+	field := strings.Split(strings.TrimSuffix(string(data), "\n"), "\t")
+	if len(field) != 4 || field[0] != "hsm" {
+		return false
+	}
+
+	*PKCS11Module, *PKCS11Token, *PKCS11Label = field[1], field[2], field[3]
+	return true
+}
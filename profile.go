@@ -0,0 +1,187 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ConfigFile  = flag.String("config", "", "path to a YAML `FILE` defining named issuance profiles")
+	ProfileName = flag.String("profile", "", "name of the profile in -config to apply")
+)
+
+// certProfile pins the parameters of a named issuance profile, à la cfssl
+// signing profiles. Every field is optional: flags already passed on the
+// command line take precedence over it, and a zero field leaves the
+// corresponding default untouched.
+type certProfile struct {
+	KeyAlgo string `yaml:"key_algo"`
+	KeySize int    `yaml:"key_size"`
+	Curve   string `yaml:"curve"`
+	Years   int    `yaml:"years"`
+
+	KeyUsage    []string `yaml:"key_usage"`
+	ExtKeyUsage []string `yaml:"ext_key_usage"`
+	Hosts       []string `yaml:"hosts"` // default SAN patterns
+
+	// CA-signing policy; only meaningful for a profile used with -ca.
+	MaxPathLen          *int     `yaml:"max_path_len"`
+	PermittedDNSDomains []string `yaml:"permitted_dns_domains"`
+}
+
+// profileConfig is the top-level shape of a -config file.
+type profileConfig struct {
+	Profiles map[string]certProfile `yaml:"profiles"`
+}
+
+var keyUsageName = map[string]x509.KeyUsage{
+	"digital_signature":  x509.KeyUsageDigitalSignature,
+	"content_commitment": x509.KeyUsageContentCommitment,
+	"key_encipherment":   x509.KeyUsageKeyEncipherment,
+	"data_encipherment":  x509.KeyUsageDataEncipherment,
+	"key_agreement":      x509.KeyUsageKeyAgreement,
+	"cert_sign":          x509.KeyUsageCertSign,
+	"crl_sign":           x509.KeyUsageCRLSign,
+}
+
+var extKeyUsageName = map[string]x509.ExtKeyUsage{
+	"server_auth":      x509.ExtKeyUsageServerAuth,
+	"client_auth":      x509.ExtKeyUsageClientAuth,
+	"code_signing":     x509.ExtKeyUsageCodeSigning,
+	"email_protection": x509.ExtKeyUsageEmailProtection,
+	"ocsp_signing":     x509.ExtKeyUsageOCSPSigning,
+	"any":              x509.ExtKeyUsageAny,
+}
+
+// activeProfile is the profile selected through -profile, once loaded.
+var activeProfile *certProfile
+
+// explicitFlags holds the name of every flag the user passed explicitly, so
+// a profile never overrides a choice made on the command line.
+var explicitFlags = map[string]bool{}
+
+// loadProfiles parses -config.
+func loadProfiles() map[string]certProfile {
+	data, err := ioutil.ReadFile(*ConfigFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cfg profileConfig
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		log.Fatal(err)
+	}
+	return cfg.Profiles
+}
+
+// ApplyProfile loads -config, if given, and applies the profile named by
+// -profile over every flag the user did not pass explicitly. It is a no-op
+// when -profile is empty.
+func ApplyProfile() {
+	if *ProfileName == "" {
+		return
+	}
+	if *ConfigFile == "" {
+		log.Fatal("`-profile` requires `-config`")
+	}
+
+	flag.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+
+	profiles := loadProfiles()
+	p, ok := profiles[*ProfileName]
+	if !ok {
+		log.Fatalf("profile %q not found in %q", *ProfileName, *ConfigFile)
+	}
+	activeProfile = &p
+
+	if p.KeyAlgo != "" && !explicitFlags["key-algo"] {
+		if err := KeyAlgo.Set(p.KeyAlgo); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if p.Curve != "" && !explicitFlags["ecdsa-curve"] {
+		if err := ECDSACurve.Set(p.Curve); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if p.KeySize != 0 && !explicitFlags["rsa-size"] {
+		if err := RSASize.Set(fmt.Sprint(p.KeySize)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if p.Years != 0 && !explicitFlags["years"] {
+		*Years = p.Years
+	}
+	if len(p.Hosts) != 0 && !explicitFlags["host"] {
+		*Host = joinHosts(p.Hosts)
+	}
+}
+
+func joinHosts(hosts []string) string {
+	return strings.Join(hosts, ",")
+}
+
+// keyUsageFor returns the profile's key usage, or fallback if no profile is
+// active or it does not set key_usage.
+func keyUsageFor(fallback x509.KeyUsage) x509.KeyUsage {
+	if activeProfile == nil || len(activeProfile.KeyUsage) == 0 {
+		return fallback
+	}
+
+	var usage x509.KeyUsage
+	for _, name := range activeProfile.KeyUsage {
+		u, ok := keyUsageName[name]
+		if !ok {
+			log.Fatalf("unknown key_usage: %q", name)
+		}
+		usage |= u
+	}
+	return usage
+}
+
+// extKeyUsageFor returns the profile's extended key usage, or fallback if no
+// profile is active or it does not set ext_key_usage.
+func extKeyUsageFor(fallback []x509.ExtKeyUsage) []x509.ExtKeyUsage {
+	if activeProfile == nil || len(activeProfile.ExtKeyUsage) == 0 {
+		return fallback
+	}
+
+	usage := make([]x509.ExtKeyUsage, len(activeProfile.ExtKeyUsage))
+	for i, name := range activeProfile.ExtKeyUsage {
+		u, ok := extKeyUsageName[name]
+		if !ok {
+			log.Fatalf("unknown ext_key_usage: %q", name)
+		}
+		usage[i] = u
+	}
+	return usage
+}
+
+// applyCAConstraints sets template's path length and name constraints from
+// the active profile, if any.
+func applyCAConstraints(template *x509.Certificate) {
+	if activeProfile == nil {
+		return
+	}
+
+	if activeProfile.MaxPathLen != nil {
+		template.MaxPathLen = *activeProfile.MaxPathLen
+		template.MaxPathLenZero = *activeProfile.MaxPathLen == 0
+	}
+	if len(activeProfile.PermittedDNSDomains) != 0 {
+		template.PermittedDNSDomains = activeProfile.PermittedDNSDomains
+		template.PermittedDNSDomainsCritical = true
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestKeyUsageForNoProfile(t *testing.T) {
+	origProfile := activeProfile
+	activeProfile = nil
+	defer func() { activeProfile = origProfile }()
+
+	fallback := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	if got := keyUsageFor(fallback); got != fallback {
+		t.Errorf("keyUsageFor(%v) = %v, want fallback unchanged", fallback, got)
+	}
+}
+
+func TestKeyUsageForProfile(t *testing.T) {
+	origProfile := activeProfile
+	activeProfile = &certProfile{KeyUsage: []string{"digital_signature", "cert_sign"}}
+	defer func() { activeProfile = origProfile }()
+
+	want := x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	if got := keyUsageFor(x509.KeyUsageKeyEncipherment); got != want {
+		t.Errorf("keyUsageFor() = %v, want %v", got, want)
+	}
+}
+
+func TestExtKeyUsageForNoProfile(t *testing.T) {
+	origProfile := activeProfile
+	activeProfile = nil
+	defer func() { activeProfile = origProfile }()
+
+	fallback := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	got := extKeyUsageFor(fallback)
+	if len(got) != 1 || got[0] != fallback[0] {
+		t.Errorf("extKeyUsageFor(%v) = %v, want fallback unchanged", fallback, got)
+	}
+}
+
+func TestExtKeyUsageForProfile(t *testing.T) {
+	origProfile := activeProfile
+	activeProfile = &certProfile{ExtKeyUsage: []string{"client_auth", "code_signing"}}
+	defer func() { activeProfile = origProfile }()
+
+	got := extKeyUsageFor([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	want := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageCodeSigning}
+	if len(got) != len(want) {
+		t.Fatalf("extKeyUsageFor() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("extKeyUsageFor()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
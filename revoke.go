@@ -0,0 +1,227 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// indexStatus is the state of a certificate recorded in File.Index, as in
+// OpenSSL's own index.txt.
+type indexStatus byte
+
+const (
+	statusValid   indexStatus = 'V'
+	statusRevoked indexStatus = 'R'
+)
+
+// indexRecord is one line of File.Index, tracking an issued certificate so
+// it can later be looked up to revoke it or to build a CRL.
+type indexRecord struct {
+	Status    indexStatus
+	NotAfter  time.Time
+	RevokedAt time.Time // Zero if Status is statusValid.
+	Serial    *big.Int
+	Subject   string
+}
+
+// indexTimeLayout is used to serialize the dates of an indexRecord.
+const indexTimeLayout = time.RFC3339
+
+// indexLine formats r as a single line of File.Index: Status, NotAfter,
+// RevokedAt (empty when not revoked), Serial and Subject, tab-separated.
+func indexLine(r *indexRecord) string {
+	revokedAt := ""
+	if r.Status == statusRevoked {
+		revokedAt = r.RevokedAt.Format(indexTimeLayout)
+	}
+	return fmt.Sprintf("%c\t%s\t%s\t%s\t%s",
+		r.Status, r.NotAfter.Format(indexTimeLayout), revokedAt, r.Serial.Text(16), r.Subject)
+}
+
+// appendIndex records a newly issued certificate in File.Index.
+func appendIndex(cert *x509.Certificate) {
+	file, err := os.OpenFile(File.Index, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	r := &indexRecord{Status: statusValid, NotAfter: cert.NotAfter, Serial: cert.SerialNumber, Subject: cert.Subject.String()}
+	if _, err = fmt.Fprintln(file, indexLine(r)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readIndex parses File.Index into its records.
+func readIndex() []*indexRecord {
+	file, err := os.Open(File.Index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []*indexRecord
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		field := strings.Split(line, "\t")
+		if len(field) != 5 {
+			log.Fatalf("malformed index record: %q", line)
+		}
+
+		notAfter, err := time.Parse(indexTimeLayout, field[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		serial, ok := new(big.Int).SetString(field[3], 16)
+		if !ok {
+			log.Fatalf("malformed serial number in index: %q", field[3])
+		}
+
+		r := &indexRecord{
+			Status:   indexStatus(field[0][0]),
+			NotAfter: notAfter,
+			Serial:   serial,
+			Subject:  field[4],
+		}
+		if r.Status == statusRevoked {
+			if r.RevokedAt, err = time.Parse(indexTimeLayout, field[2]); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		records = append(records, r)
+	}
+	if err = scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	return records
+}
+
+// writeIndex rewrites File.Index with records.
+func writeIndex(records []*indexRecord) {
+	var buf strings.Builder
+
+	for _, r := range records {
+		buf.WriteString(indexLine(r))
+		buf.WriteByte('\n')
+	}
+
+	if err := ioutil.WriteFile(File.Index, []byte(buf.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Revoke marks the certificate named name as revoked in File.Index.
+func Revoke(name string) {
+	cert, err := x509.ParseCertificate(readBlock(filepath.Join(Dir.Cert, name+EXT_CERT)).Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	records := readIndex()
+
+	for _, r := range records {
+		if r.Serial.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		if r.Status == statusRevoked {
+			log.Fatalf("certificate %q is already revoked", name)
+		}
+
+		r.Status = statusRevoked
+		r.RevokedAt = time.Now()
+		writeIndex(records)
+
+		fmt.Printf("* Certificate %q revoked\n", name)
+		return
+	}
+
+	log.Fatalf("certificate %q is not present in %q", name, File.Index)
+}
+
+// GenCRL builds a fresh CRL covering every revoked certificate and signs it
+// with the CA's key.
+func GenCRL() {
+	caCert, caKey := loadCA()
+
+	var revoked []pkix.RevokedCertificate
+	for _, r := range readIndex() {
+		if r.Status != statusRevoked {
+			continue
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   r.Serial,
+			RevocationTime: r.RevokedAt,
+		})
+	}
+
+	der, err := caCert.CreateCRL(rand.Reader, caKey, revoked, time.Now(), time.Now().AddDate(0, 0, 7))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	crlFile := filepath.Join(Dir.Revok, _NAME_CA+EXT_REVOK)
+	file, err := os.OpenFile(crlFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err = pem.Encode(file, &pem.Block{Type: "X509 CRL", Bytes: der}); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("* CRL generated in %q (%d certificate(s) revoked)\n", crlFile, len(revoked))
+}
+
+// checkCRL reports whether serial is listed in the CA's CRL, and since when.
+func checkCRL(serial *big.Int) (revoked bool, revokedAt time.Time) {
+	crlFile := filepath.Join(Dir.Revok, _NAME_CA+EXT_REVOK)
+
+	data, err := ioutil.ReadFile(crlFile)
+	if err != nil {
+		return false, time.Time{}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		log.Fatalf("no PEM data found in %q", crlFile)
+	}
+
+	list, err := x509.ParseCRL(block.Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, c := range list.TBSCertList.RevokedCertificates {
+		if c.SerialNumber.Cmp(serial) == 0 {
+			return true, c.RevocationTime
+		}
+	}
+	return false, time.Time{}
+}
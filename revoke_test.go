@@ -0,0 +1,56 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestIndexRoundTrip checks that writeIndex/readIndex preserve every field
+// of an indexRecord, including the revoked ones that indexLine formats
+// differently from a still-valid certificate.
+func TestIndexRoundTrip(t *testing.T) {
+	origIndex := File.Index
+	File.Index = filepath.Join(t.TempDir(), "index.txt")
+	defer func() { File.Index = origIndex }()
+
+	now := time.Now().Truncate(time.Second).UTC()
+
+	want := []*indexRecord{
+		{Status: statusValid, NotAfter: now.AddDate(1, 0, 0), Serial: big.NewInt(1), Subject: "CN=valid"},
+		{Status: statusRevoked, NotAfter: now.AddDate(1, 0, 0), RevokedAt: now, Serial: big.NewInt(255), Subject: "CN=revoked"},
+	}
+
+	writeIndex(want)
+	got := readIndex()
+
+	if len(got) != len(want) {
+		t.Fatalf("readIndex returned %d records, want %d", len(got), len(want))
+	}
+
+	for i, w := range want {
+		r := got[i]
+		if r.Status != w.Status {
+			t.Errorf("record %d: Status = %q, want %q", i, r.Status, w.Status)
+		}
+		if !r.NotAfter.Equal(w.NotAfter) {
+			t.Errorf("record %d: NotAfter = %s, want %s", i, r.NotAfter, w.NotAfter)
+		}
+		if r.Serial.Cmp(w.Serial) != 0 {
+			t.Errorf("record %d: Serial = %s, want %s", i, r.Serial.Text(16), w.Serial.Text(16))
+		}
+		if r.Subject != w.Subject {
+			t.Errorf("record %d: Subject = %q, want %q", i, r.Subject, w.Subject)
+		}
+		if w.Status == statusRevoked && !r.RevokedAt.Equal(w.RevokedAt) {
+			t.Errorf("record %d: RevokedAt = %s, want %s", i, r.RevokedAt, w.RevokedAt)
+		}
+	}
+}